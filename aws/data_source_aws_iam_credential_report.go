@@ -0,0 +1,335 @@
+// This is a Fugue-specific read-only data source that exposes the AWS IAM
+// Credential Report without requiring callers to declare a resource for
+// read-only data, plus a set of pre-computed IAM hygiene findings so policy
+// engines don't need to re-parse CSV columns themselves.
+
+package aws
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func dataSourceAwsIamCredentialReport() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceAwsIamCredentialReportRead,
+
+		Schema: dataSourceAwsIamCredentialReportSchema(),
+	}
+}
+
+func dataSourceAwsIamCredentialReportSchema() map[string]*schema.Schema {
+	s := map[string]*schema.Schema{
+		"max_report_age": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Default:     "4h",
+			Description: "Reuse the existing IAM credential report if it is younger than this duration, instead of generating a new one.",
+		},
+		"force_refresh": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Always generate a fresh credential report, ignoring max_report_age.",
+		},
+		"generated_time": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"raw_csv": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The raw IAM credential report CSV, base64-encoded, for callers that need to fall back to their own parser.",
+		},
+		"report": {
+			Type:     schema.TypeList,
+			Computed: true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"user": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"password_enabled": {
+						Type:     schema.TypeBool,
+						Computed: true,
+					},
+					"password_last_used": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"password_last_used_known": {
+						Type:     schema.TypeBool,
+						Computed: true,
+					},
+					"password_last_changed": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"password_last_changed_known": {
+						Type:     schema.TypeBool,
+						Computed: true,
+					},
+					"mfa_active": {
+						Type:     schema.TypeBool,
+						Computed: true,
+					},
+					"mfa_virtual": {
+						Type:     schema.TypeBool,
+						Computed: true,
+					},
+					"access_keys": {
+						Type:     schema.TypeList,
+						Computed: true,
+						Elem: &schema.Resource{
+							Schema: credentialReportAccessKeySchema(),
+						},
+					},
+					"cert_1": {
+						Type:     schema.TypeList,
+						Computed: true,
+						MaxItems: 1,
+						Elem: &schema.Resource{
+							Schema: credentialReportCertSchema(),
+						},
+					},
+					"cert_2": {
+						Type:     schema.TypeList,
+						Computed: true,
+						MaxItems: 1,
+						Elem: &schema.Resource{
+							Schema: credentialReportCertSchema(),
+						},
+					},
+					"services": {
+						Type:     schema.TypeList,
+						Computed: true,
+						Elem: &schema.Resource{
+							Schema: map[string]*schema.Schema{
+								"service_namespace": {
+									Type:     schema.TypeString,
+									Computed: true,
+								},
+								"last_authenticated": {
+									Type:     schema.TypeString,
+									Computed: true,
+								},
+								"region": {
+									Type:     schema.TypeString,
+									Computed: true,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		"include_service_last_accessed": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Enrich each user with their service last accessed details. This adds a GenerateServiceLastAccessedDetails call per user and can slow down refreshes significantly.",
+		},
+		"findings": {
+			Type:     schema.TypeList,
+			Computed: true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"user": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"control_id": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"severity": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"field": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"description": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+				},
+			},
+		},
+	}
+
+	for k, v := range credentialReportThresholdsSchema() {
+		s[k] = v
+	}
+
+	return s
+}
+
+func dataSourceAwsIamCredentialReportRead(d *schema.ResourceData, meta interface{}) error {
+	iamconn := meta.(*AWSClient).iamconn
+
+	maxReportAge, err := time.ParseDuration(d.Get("max_report_age").(string))
+	if err != nil {
+		return fmt.Errorf("invalid max_report_age: %s", err)
+	}
+
+	report, generatedTime, rawCSV, err := getCredentialReport(iamconn, maxReportAge, d.Get("force_refresh").(bool), d.Get("include_service_last_accessed").(bool))
+	if err != nil {
+		return err
+	}
+
+	findings := computeCredentialReportFindings(report, credentialReportThresholdsFromResourceData(d))
+
+	d.SetId("iam-credential-report")
+	d.Set("generated_time", generatedTime.Format(time.RFC3339))
+	d.Set("report", flattenCredentialReport(report))
+	d.Set("findings", flattenCredentialReportFindings(findings))
+	d.Set("raw_csv", base64.StdEncoding.EncodeToString(rawCSV))
+
+	return nil
+}
+
+// credentialReportThresholds holds the configurable ages (in days) used to
+// decide whether a password or access key is considered stale or unused.
+type credentialReportThresholds struct {
+	MaxKeyAgeDays       int
+	MaxPasswordAgeDays  int
+	UnusedThresholdDays int
+}
+
+// CredentialReportFinding is a single IAM best-practice violation detected
+// for a user in the credential report, shaped so that policy engines like
+// Fugue or Regula can consume it without re-parsing CSV columns.
+type CredentialReportFinding struct {
+	User        string
+	ControlId   string
+	Severity    string
+	Field       string
+	Description string
+}
+
+// computeCredentialReportFindings walks the parsed credential report and
+// flags common IAM hygiene violations per user.
+func computeCredentialReportFindings(report CredentialReport, thresholds credentialReportThresholds) []CredentialReportFinding {
+	findings := []CredentialReportFinding{}
+
+	for _, row := range report {
+		if row.PasswordEnabled && !row.MfaActive {
+			findings = append(findings, CredentialReportFinding{
+				User:        row.User,
+				ControlId:   "password_enabled_without_mfa",
+				Severity:    "high",
+				Field:       "mfa_active",
+				Description: fmt.Sprintf("user %q has console password access but no MFA device enabled", row.User),
+			})
+		}
+
+		if row.PasswordEnabled && !row.PasswordLastChangedKnown {
+			findings = append(findings, CredentialReportFinding{
+				User:        row.User,
+				ControlId:   "password_never_rotated",
+				Severity:    "medium",
+				Field:       "password_last_changed",
+				Description: fmt.Sprintf("user %q has never rotated their console password", row.User),
+			})
+		}
+
+		if row.PasswordEnabled {
+			if age, ok := credentialReportFieldAgeDays(row.PasswordLastChanged); ok && age > thresholds.MaxPasswordAgeDays {
+				findings = append(findings, CredentialReportFinding{
+					User:        row.User,
+					ControlId:   "password_older_than_90_days",
+					Severity:    "medium",
+					Field:       "password_last_changed",
+					Description: fmt.Sprintf("user %q console password is %d days old", row.User, age),
+				})
+			}
+		}
+
+		if row.User == "<root_account>" {
+			for i, key := range row.AccessKeys {
+				if key.Active {
+					findings = append(findings, CredentialReportFinding{
+						User:        row.User,
+						ControlId:   "root_account_access_key_present",
+						Severity:    "critical",
+						Field:       fmt.Sprintf("access_key_%d_active", i+1),
+						Description: "the root account has an active access key",
+					})
+				}
+			}
+		}
+
+		for i, key := range row.AccessKeys {
+			if !key.Active {
+				continue
+			}
+
+			if age, ok := credentialReportFieldAgeDays(key.LastRotated); ok && age > thresholds.MaxKeyAgeDays {
+				findings = append(findings, CredentialReportFinding{
+					User:        row.User,
+					ControlId:   "access_key_older_than_90_days",
+					Severity:    "medium",
+					Field:       fmt.Sprintf("access_key_%d_last_rotated", i+1),
+					Description: fmt.Sprintf("user %q access key %d is %d days old", row.User, i+1, age),
+				})
+			}
+
+			// A key that has never been used has an unknown last-used date,
+			// which is only a finding once the key itself is old enough that
+			// "never used yet" is no longer explained by it being new.
+			rotatedAge, rotatedKnown := credentialReportFieldAgeDays(key.LastRotated)
+			if rotatedKnown && rotatedAge <= thresholds.UnusedThresholdDays {
+				continue
+			}
+
+			if age, ok := credentialReportFieldAgeDays(key.LastUsedDate); !ok || age > thresholds.UnusedThresholdDays {
+				findings = append(findings, CredentialReportFinding{
+					User:        row.User,
+					ControlId:   "unused_access_key_over_90_days",
+					Severity:    "low",
+					Field:       fmt.Sprintf("access_key_%d_last_used_date", i+1),
+					Description: fmt.Sprintf("user %q access key %d has not been used in over %d days", row.User, i+1, thresholds.UnusedThresholdDays),
+				})
+			}
+		}
+	}
+
+	return findings
+}
+
+// credentialReportFieldAgeDays returns the age in days of an RFC3339
+// timestamp as reported in the credential report CSV. parseCsvTimestamp
+// already normalizes AWS's sentinel values ("N/A", "no_information") to "",
+// so an empty or otherwise unparseable value returns ok == false.
+func credentialReportFieldAgeDays(value string) (int, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, false
+	}
+
+	return int(time.Since(t).Hours() / 24), true
+}
+
+func flattenCredentialReportFindings(findings []CredentialReportFinding) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(findings))
+	for _, finding := range findings {
+		out = append(out, map[string]interface{}{
+			"user":        finding.User,
+			"control_id":  finding.ControlId,
+			"severity":    finding.Severity,
+			"field":       finding.Field,
+			"description": finding.Description,
+		})
+	}
+	return out
+}
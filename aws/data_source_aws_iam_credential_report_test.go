@@ -0,0 +1,128 @@
+package aws
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCredentialReportFieldAgeDays(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{name: "empty is unknown", value: "", ok: false},
+		{name: "valid timestamp is known", value: "2020-01-01T00:00:00Z", ok: true},
+		{name: "unparseable value is unknown", value: "not-a-timestamp", ok: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ok := credentialReportFieldAgeDays(tc.value)
+			if ok != tc.ok {
+				t.Errorf("credentialReportFieldAgeDays(%q) ok = %v, want %v", tc.value, ok, tc.ok)
+			}
+		})
+	}
+}
+
+func TestComputeCredentialReportFindingsPasswordWithoutMfa(t *testing.T) {
+	report := CredentialReport{
+		{User: "alice", PasswordEnabled: true, MfaActive: false, PasswordLastChanged: "2020-01-01T00:00:00Z", PasswordLastChangedKnown: true},
+	}
+
+	findings := computeCredentialReportFindings(report, credentialReportThresholds{MaxKeyAgeDays: 90, MaxPasswordAgeDays: 90, UnusedThresholdDays: 90})
+
+	if !hasFinding(findings, "alice", "password_enabled_without_mfa") {
+		t.Errorf("expected password_enabled_without_mfa finding for alice, got %+v", findings)
+	}
+}
+
+func TestComputeCredentialReportFindingsRootAccessKey(t *testing.T) {
+	report := CredentialReport{
+		{User: "<root_account>", AccessKeys: []AccessKey{{Active: true}, {Active: false}}},
+	}
+
+	findings := computeCredentialReportFindings(report, credentialReportThresholds{MaxKeyAgeDays: 90, MaxPasswordAgeDays: 90, UnusedThresholdDays: 90})
+
+	if !hasFinding(findings, "<root_account>", "root_account_access_key_present") {
+		t.Errorf("expected root_account_access_key_present finding, got %+v", findings)
+	}
+}
+
+func TestComputeCredentialReportFindingsFreshlyRotatedKeyIsNotFlaggedUnused(t *testing.T) {
+	report := CredentialReport{
+		{
+			User: "bob",
+			AccessKeys: []AccessKey{
+				{
+					Active:            true,
+					LastRotated:       time.Now().Format(time.RFC3339),
+					LastRotatedKnown:  true,
+					LastUsedDateKnown: false,
+				},
+			},
+		},
+	}
+
+	findings := computeCredentialReportFindings(report, credentialReportThresholds{MaxKeyAgeDays: 90, MaxPasswordAgeDays: 90, UnusedThresholdDays: 90})
+
+	if hasFinding(findings, "bob", "unused_access_key_over_90_days") {
+		t.Errorf("did not expect unused_access_key_over_90_days finding for a freshly rotated, never-used key, got %+v", findings)
+	}
+}
+
+func TestComputeCredentialReportFindingsOldUnusedKeyIsFlagged(t *testing.T) {
+	report := CredentialReport{
+		{
+			User: "carol",
+			AccessKeys: []AccessKey{
+				{
+					Active:            true,
+					LastRotated:       "2000-01-01T00:00:00Z",
+					LastRotatedKnown:  true,
+					LastUsedDateKnown: false,
+				},
+			},
+		},
+	}
+
+	findings := computeCredentialReportFindings(report, credentialReportThresholds{MaxKeyAgeDays: 90, MaxPasswordAgeDays: 90, UnusedThresholdDays: 90})
+
+	if !hasFinding(findings, "carol", "unused_access_key_over_90_days") {
+		t.Errorf("expected unused_access_key_over_90_days finding for an old, never-used key, got %+v", findings)
+	}
+}
+
+func TestComputeCredentialReportFindingsOldPasswordIsFlagged(t *testing.T) {
+	report := CredentialReport{
+		{User: "dave", PasswordEnabled: true, MfaActive: true, PasswordLastChanged: "2000-01-01T00:00:00Z", PasswordLastChangedKnown: true},
+	}
+
+	findings := computeCredentialReportFindings(report, credentialReportThresholds{MaxKeyAgeDays: 90, MaxPasswordAgeDays: 90, UnusedThresholdDays: 90})
+
+	if !hasFinding(findings, "dave", "password_older_than_90_days") {
+		t.Errorf("expected password_older_than_90_days finding for a password last changed in 2000, got %+v", findings)
+	}
+}
+
+func TestComputeCredentialReportFindingsRecentPasswordIsNotFlagged(t *testing.T) {
+	report := CredentialReport{
+		{User: "erin", PasswordEnabled: true, MfaActive: true, PasswordLastChanged: time.Now().Format(time.RFC3339), PasswordLastChangedKnown: true},
+	}
+
+	findings := computeCredentialReportFindings(report, credentialReportThresholds{MaxKeyAgeDays: 90, MaxPasswordAgeDays: 90, UnusedThresholdDays: 90})
+
+	if hasFinding(findings, "erin", "password_older_than_90_days") {
+		t.Errorf("did not expect password_older_than_90_days finding for a recently changed password, got %+v", findings)
+	}
+}
+
+func hasFinding(findings []CredentialReportFinding, user, controlID string) bool {
+	for _, f := range findings {
+		if f.User == user && f.ControlId == controlID {
+			return true
+		}
+	}
+	return false
+}
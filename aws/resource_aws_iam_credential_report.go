@@ -5,77 +5,165 @@ package aws
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"regexp"
+	"sort"
 	"time"
 
+	"encoding/base64"
 	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/iam"
 
-	"github.com/hashicorp/terraform/helper/resource"
 	"github.com/hashicorp/terraform/helper/schema"
 )
 
+// credentialReportOutputSchemaVersion is stamped into output_json_path and
+// output_ndjson_path documents so downstream policy tooling can detect
+// breaking changes to the emitted schema across provider versions.
+const credentialReportOutputSchemaVersion = 1
+
+// credentialReportPollTimeout bounds how long getCredentialReport will keep
+// retrying GetCredentialReport while AWS reports "ReportInProgress".
+const credentialReportPollTimeout = 10 * time.Minute
+
+// credentialReportPollMaxBackoff caps the exponential backoff between polls
+// so a slow report generation doesn't end up waiting many minutes between
+// attempts.
+const credentialReportPollMaxBackoff = 30 * time.Second
+
+// credentialReportIAMAPI is the subset of *iam.IAM that the credential
+// report read path needs, narrowed so tests can supply a fake instead of
+// standing up a real IAM client.
+type credentialReportIAMAPI interface {
+	GetCredentialReport(*iam.GetCredentialReportInput) (*iam.GetCredentialReportOutput, error)
+	GenerateCredentialReport(*iam.GenerateCredentialReportInput) (*iam.GenerateCredentialReportOutput, error)
+	ListVirtualMFADevices(*iam.ListVirtualMFADevicesInput) (*iam.ListVirtualMFADevicesOutput, error)
+	GenerateServiceLastAccessedDetails(*iam.GenerateServiceLastAccessedDetailsInput) (*iam.GenerateServiceLastAccessedDetailsOutput, error)
+	GetServiceLastAccessedDetails(*iam.GetServiceLastAccessedDetailsInput) (*iam.GetServiceLastAccessedDetailsOutput, error)
+}
+
 func resourceAwsIamCredentialReport() *schema.Resource {
-	return &schema.Resource{
-		Create: resourceAwsIamCredentialReportUpdate,
-		Read:   resourceAwsIamCredentialReportRead,
-		Update: resourceAwsIamCredentialReportUpdate,
-		Delete: resourceAwsIamCredentialReportDelete,
-		Importer: &schema.ResourceImporter{
-			State: schema.ImportStatePassthrough,
+	resourceSchema := map[string]*schema.Schema{
+		"max_report_age": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Default:     "4h",
+			Description: "Reuse the existing IAM credential report if it is younger than this duration, instead of generating a new one.",
 		},
-
-		Schema: map[string]*schema.Schema{
-			"report": {
-				Type:     schema.TypeList,
-				Optional: true,
-				Computed: true,
-				Elem: &schema.Resource{
-					Schema: map[string]*schema.Schema{
-						"user": {
-							Type:     schema.TypeString,
-							Computed: true,
-						},
-						"password_enabled": {
-							Type:     schema.TypeBool,
-							Computed: true,
-						},
-						"password_last_used": {
-							Type:     schema.TypeString,
-							Computed: true,
-						},
-						"password_last_changed": {
-							Type:     schema.TypeString,
-							Computed: true,
+		"force_refresh": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Always generate a fresh credential report, ignoring max_report_age.",
+		},
+		"generated_time": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"output_json_path": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "Local path to write the enriched report and findings as a single JSON document.",
+		},
+		"output_ndjson_path": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			Description: "Local path to write the enriched report and findings as newline-delimited JSON, one record per line.",
+		},
+		"checksum": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "SHA-256 checksum (hex) of the document written to output_json_path, or output_ndjson_path if only that is set.",
+		},
+		"raw_csv": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The raw IAM credential report CSV, base64-encoded, for callers that need to fall back to their own parser.",
+		},
+		"report": {
+			Type:     schema.TypeList,
+			Optional: true,
+			Computed: true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"user": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"password_enabled": {
+						Type:     schema.TypeBool,
+						Computed: true,
+					},
+					"password_last_used": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"password_last_changed": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"mfa_active": {
+						Type:     schema.TypeBool,
+						Computed: true,
+					},
+					"mfa_virtual": {
+						Type:     schema.TypeBool,
+						Computed: true,
+					},
+					"password_last_used_known": {
+						Type:     schema.TypeBool,
+						Computed: true,
+					},
+					"password_last_changed_known": {
+						Type:     schema.TypeBool,
+						Computed: true,
+					},
+					"access_keys": {
+						Type:     schema.TypeList,
+						Computed: true,
+						Elem: &schema.Resource{
+							Schema: credentialReportAccessKeySchema(),
 						},
-						"mfa_active": {
-							Type:     schema.TypeBool,
-							Computed: true,
+					},
+					"cert_1": {
+						Type:     schema.TypeList,
+						Computed: true,
+						MaxItems: 1,
+						Elem: &schema.Resource{
+							Schema: credentialReportCertSchema(),
 						},
-						"mfa_virtual": {
-							Type:     schema.TypeBool,
-							Computed: true,
+					},
+					"cert_2": {
+						Type:     schema.TypeList,
+						Computed: true,
+						MaxItems: 1,
+						Elem: &schema.Resource{
+							Schema: credentialReportCertSchema(),
 						},
-						"access_keys": {
-							Type:     schema.TypeList,
-							Computed: true,
-							Elem: &schema.Resource{
-								Schema: map[string]*schema.Schema{
-									"active": {
-										Type:     schema.TypeBool,
-										Computed: true,
-									},
-									"last_used_date": {
-										Type:     schema.TypeString,
-										Computed: true,
-									},
-									"last_rotated": {
-										Type:     schema.TypeString,
-										Computed: true,
-									},
+					},
+					"services": {
+						Type:     schema.TypeList,
+						Computed: true,
+						Elem: &schema.Resource{
+							Schema: map[string]*schema.Schema{
+								"service_namespace": {
+									Type:     schema.TypeString,
+									Computed: true,
+								},
+								"last_authenticated": {
+									Type:     schema.TypeString,
+									Computed: true,
+								},
+								"region": {
+									Type:     schema.TypeString,
+									Computed: true,
 								},
 							},
 						},
@@ -83,6 +171,117 @@ func resourceAwsIamCredentialReport() *schema.Resource {
 				},
 			},
 		},
+		"include_service_last_accessed": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Enrich each user with their service last accessed details. This adds a GenerateServiceLastAccessedDetails call per user and can slow down refreshes significantly.",
+		},
+	}
+
+	for k, v := range credentialReportThresholdsSchema() {
+		resourceSchema[k] = v
+	}
+
+	return &schema.Resource{
+		Create: resourceAwsIamCredentialReportUpdate,
+		Read:   resourceAwsIamCredentialReportRead,
+		Update: resourceAwsIamCredentialReportUpdate,
+		Delete: resourceAwsIamCredentialReportDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+		Schema: resourceSchema,
+	}
+}
+
+// credentialReportThresholdsSchema returns the schema for the configurable
+// ages used to compute findings. It is shared by resourceAwsIamCredentialReport
+// and dataSourceAwsIamCredentialReport.
+func credentialReportThresholdsSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"max_key_age_days": {
+			Type:     schema.TypeInt,
+			Optional: true,
+			Default:  90,
+		},
+		"max_password_age_days": {
+			Type:     schema.TypeInt,
+			Optional: true,
+			Default:  90,
+		},
+		"unused_threshold_days": {
+			Type:     schema.TypeInt,
+			Optional: true,
+			Default:  90,
+		},
+	}
+}
+
+func credentialReportThresholdsFromResourceData(d *schema.ResourceData) credentialReportThresholds {
+	return credentialReportThresholds{
+		MaxKeyAgeDays:       d.Get("max_key_age_days").(int),
+		MaxPasswordAgeDays:  d.Get("max_password_age_days").(int),
+		UnusedThresholdDays: d.Get("unused_threshold_days").(int),
+	}
+}
+
+// credentialReportAccessKeySchema returns the schema for a single
+// access_keys entry. It is shared by resourceAwsIamCredentialReport and
+// dataSourceAwsIamCredentialReport.
+func credentialReportAccessKeySchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"active": {
+			Type:     schema.TypeBool,
+			Computed: true,
+		},
+		"last_used_date": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"last_rotated": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"last_used_region": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"last_used_service": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"last_used_date_known": {
+			Type:     schema.TypeBool,
+			Computed: true,
+		},
+		"last_rotated_known": {
+			Type:     schema.TypeBool,
+			Computed: true,
+		},
+	}
+}
+
+// credentialReportCertSchema returns the schema for a single cert_1/cert_2
+// entry. It is a single-item list of a nested resource so
+// callers get native bool fields instead of having to string-compare
+// "true"/"false", matching access_keys. It is shared by
+// resourceAwsIamCredentialReport, dataSourceAwsIamCredentialReport and
+// resourceAwsIamCredentialReportAggregated.
+func credentialReportCertSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"active": {
+			Type:     schema.TypeBool,
+			Computed: true,
+		},
+		"last_rotated": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"last_rotated_known": {
+			Type:     schema.TypeBool,
+			Computed: true,
+		},
 	}
 }
 
@@ -94,70 +293,362 @@ func resourceAwsIamCredentialReportUpdate(d *schema.ResourceData, meta interface
 func resourceAwsIamCredentialReportRead(d *schema.ResourceData, meta interface{}) error {
 	iamconn := meta.(*AWSClient).iamconn
 
-	// Send a request to generate a credential report.
-	generateReportInput := &iam.GenerateCredentialReportInput{}
-	if _, err := iamconn.GenerateCredentialReport(generateReportInput); err != nil {
+	maxReportAge, err := time.ParseDuration(d.Get("max_report_age").(string))
+	if err != nil {
+		return fmt.Errorf("invalid max_report_age: %s", err)
+	}
+
+	report, generatedTime, rawCSV, err := getCredentialReport(iamconn, maxReportAge, d.Get("force_refresh").(bool), d.Get("include_service_last_accessed").(bool))
+	if err != nil {
+		return err
+	}
+
+	// Store report in the resource state.
+	d.Set("generated_time", generatedTime.Format(time.RFC3339))
+	d.Set("report", flattenCredentialReport(report))
+	d.Set("raw_csv", base64.StdEncoding.EncodeToString(rawCSV))
+
+	checksum, err := writeCredentialReportOutputs(d, report, generatedTime)
+	if err != nil {
 		return err
 	}
+	d.Set("checksum", checksum)
+
+	return nil
+}
+
+// credentialReportOutputDocument is the stable, versioned shape written to
+// output_json_path and output_ndjson_path so external Fugue/Regula/OPA
+// pipelines can consume the enriched report from disk without re-running
+// Terraform state introspection.
+type credentialReportOutputDocument struct {
+	SchemaVersion int                      `json:"schema_version"`
+	GeneratedTime string                   `json:"generated_time"`
+	Report        []map[string]interface{} `json:"report"`
+	Findings      []map[string]interface{} `json:"findings"`
+}
+
+// writeCredentialReportOutputs writes output_json_path and/or
+// output_ndjson_path, if configured, and returns the checksum of whichever
+// was written (preferring the JSON document when both are set).
+func writeCredentialReportOutputs(d *schema.ResourceData, report CredentialReport, generatedTime time.Time) (string, error) {
+	jsonPath := d.Get("output_json_path").(string)
+	ndjsonPath := d.Get("output_ndjson_path").(string)
+	if jsonPath == "" && ndjsonPath == "" {
+		return "", nil
+	}
+
+	findings := computeCredentialReportFindings(report, credentialReportThresholdsFromResourceData(d))
+	doc := credentialReportOutputDocument{
+		SchemaVersion: credentialReportOutputSchemaVersion,
+		GeneratedTime: generatedTime.Format(time.RFC3339),
+		Report:        flattenCredentialReport(report),
+		Findings:      flattenCredentialReportFindings(findings),
+	}
 
-	return resource.Retry(time.Duration(1)*time.Minute, func() *resource.RetryError {
-		// Prepare a request to actually get the credential report.
-		getReportInput := &iam.GetCredentialReportInput{}
-		getReportOutput, err := iamconn.GetCredentialReport(getReportInput)
+	var checksum string
+	if jsonPath != "" {
+		sum, err := writeCredentialReportJSON(jsonPath, doc)
 		if err != nil {
-			if awserr, ok := err.(awserr.Error); ok {
-				switch awserr.Code() {
-				// Retry if it is still being generated.
-				case "ReportInProgress":
-					return resource.RetryableError(awserr)
-				}
-			}
-			return resource.NonRetryableError(err)
+			return "", fmt.Errorf("writing output_json_path: %s", err)
 		}
+		checksum = sum
+	}
 
-		// Parse report.
-		log.Printf("[INFO]: Credential Report Content: %s", string(getReportOutput.Content))
-		report, err := parseCsvCredentialReport(getReportOutput.Content)
+	if ndjsonPath != "" {
+		sum, err := writeCredentialReportNDJSON(ndjsonPath, doc)
 		if err != nil {
-			return resource.NonRetryableError(err)
+			return "", fmt.Errorf("writing output_ndjson_path: %s", err)
+		}
+		if checksum == "" {
+			checksum = sum
 		}
+	}
 
-		// Retrieve info about virtual MFA devices.
-		listMfaInput := &iam.ListVirtualMFADevicesInput{}
-		listMfaOutput, err := iamconn.ListVirtualMFADevices(listMfaInput)
-		if err != nil {
-			return resource.NonRetryableError(err)
+	return checksum, nil
+}
+
+func writeCredentialReportJSON(path string, doc credentialReportOutputDocument) (string, error) {
+	content, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		return "", err
+	}
+
+	return credentialReportChecksum(content), nil
+}
+
+// writeCredentialReportNDJSON writes one JSON object per line: a leading
+// metadata record, one record per report row, then one record per finding.
+func writeCredentialReportNDJSON(path string, doc credentialReportOutputDocument) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+
+	if err := encoder.Encode(map[string]interface{}{
+		"type":           "metadata",
+		"schema_version": doc.SchemaVersion,
+		"generated_time": doc.GeneratedTime,
+	}); err != nil {
+		return "", err
+	}
+
+	for _, row := range doc.Report {
+		if err := encoder.Encode(credentialReportNdjsonRecord(row, "user")); err != nil {
+			return "", err
 		}
+	}
 
-		// Run through the virtual MFA devices to create a set of users that
-		// have them enabled.  The user names are constructed to match those in
-		// the credential report.
-		accountsWithVirtualMfa := map[string]bool{}
-		serial, _ := regexp.Compile("^arn:aws:iam::[0-9]+:mfa/(.*)$")
-		for _, virtualMfa := range listMfaOutput.VirtualMFADevices {
-			match := serial.FindStringSubmatch(*virtualMfa.SerialNumber)
-			if match != nil && len(match) > 1 {
-				accountName := match[1]
-				if accountName == "root-account-mfa-device" {
-					accountName = "<root_account>"
-				}
+	for _, finding := range doc.Findings {
+		if err := encoder.Encode(credentialReportNdjsonRecord(finding, "finding")); err != nil {
+			return "", err
+		}
+	}
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return "", err
+	}
+
+	return credentialReportChecksum(buf.Bytes()), nil
+}
+
+// credentialReportNdjsonRecord copies a record and tags it with a "type" so
+// a single NDJSON file can mix rows and findings without ambiguity.
+func credentialReportNdjsonRecord(record map[string]interface{}, recordType string) map[string]interface{} {
+	out := make(map[string]interface{}, len(record)+1)
+	for k, v := range record {
+		out[k] = v
+	}
+	out["type"] = recordType
+	return out
+}
+
+func credentialReportChecksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
 
-				accountsWithVirtualMfa[accountName] = true
+// getCredentialReport fetches the IAM credential report, parses it and
+// enriches it with virtual MFA device info. It is shared by
+// resourceAwsIamCredentialReport and dataSourceAwsIamCredentialReport so
+// both stay in sync with AWS's CSV format.
+//
+// If an existing report is present and younger than maxReportAge, it is
+// reused instead of generating a new one; pass forceRefresh to always
+// generate a fresh report. Pass includeServiceLastAccessed to additionally
+// enrich each row with GenerateServiceLastAccessedDetails output, which adds
+// one API round-trip per user.
+func getCredentialReport(iamconn credentialReportIAMAPI, maxReportAge time.Duration, forceRefresh, includeServiceLastAccessed bool) (CredentialReport, time.Time, []byte, error) {
+	if !forceRefresh {
+		getReportOutput, err := iamconn.GetCredentialReport(&iam.GetCredentialReportInput{})
+		if err == nil {
+			if getReportOutput.GeneratedTime != nil && time.Since(*getReportOutput.GeneratedTime) <= maxReportAge {
+				return parseAndEnrichCredentialReport(iamconn, getReportOutput, includeServiceLastAccessed)
+			}
+		} else if awserr, ok := err.(awserr.Error); ok {
+			switch awserr.Code() {
+			// A generation is already underway (kicked off by us or another
+			// caller, e.g. a concurrent apply): wait for it instead of
+			// starting a redundant one.
+			case "ReportInProgress":
+				getReportOutput, err := pollCredentialReport(iamconn)
+				if err != nil {
+					return nil, time.Time{}, nil, err
+				}
+				return parseAndEnrichCredentialReport(iamconn, getReportOutput, includeServiceLastAccessed)
+			// No usable report yet; fall through and generate one.
+			case "ReportNotPresent", "ReportExpired":
+			default:
+				return nil, time.Time{}, nil, err
 			}
+		} else {
+			return nil, time.Time{}, nil, err
+		}
+	}
+
+	// Send a request to generate a fresh credential report.
+	generateReportInput := &iam.GenerateCredentialReportInput{}
+	if _, err := iamconn.GenerateCredentialReport(generateReportInput); err != nil {
+		return nil, time.Time{}, nil, err
+	}
+
+	getReportOutput, err := pollCredentialReport(iamconn)
+	if err != nil {
+		return nil, time.Time{}, nil, err
+	}
+
+	return parseAndEnrichCredentialReport(iamconn, getReportOutput, includeServiceLastAccessed)
+}
+
+// pollCredentialReport polls GetCredentialReport until it is ready, backing
+// off exponentially (capped at credentialReportPollMaxBackoff) between
+// attempts instead of hammering the API on a fixed interval.
+func pollCredentialReport(iamconn credentialReportIAMAPI) (*iam.GetCredentialReportOutput, error) {
+	deadline := time.Now().Add(credentialReportPollTimeout)
+	backoff := 1 * time.Second
+
+	for {
+		getReportOutput, err := iamconn.GetCredentialReport(&iam.GetCredentialReportInput{})
+		if err == nil {
+			return getReportOutput, nil
+		}
+
+		awserr, ok := err.(awserr.Error)
+		if !ok || awserr.Code() != "ReportInProgress" {
+			return nil, err
+		}
+
+		if time.Now().Add(backoff).After(deadline) {
+			return nil, fmt.Errorf("timed out after %s waiting for IAM credential report generation: %s", credentialReportPollTimeout, awserr)
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > credentialReportPollMaxBackoff {
+			backoff = credentialReportPollMaxBackoff
+		}
+	}
+}
+
+func parseAndEnrichCredentialReport(iamconn credentialReportIAMAPI, getReportOutput *iam.GetCredentialReportOutput, includeServiceLastAccessed bool) (CredentialReport, time.Time, []byte, error) {
+	log.Printf("[INFO]: Credential Report Content: %s", string(getReportOutput.Content))
+	report, err := parseCsvCredentialReport(getReportOutput.Content)
+	if err != nil {
+		return nil, time.Time{}, nil, err
+	}
+
+	if err := enrichCredentialReportWithVirtualMfa(iamconn, report); err != nil {
+		return nil, time.Time{}, nil, err
+	}
+
+	if includeServiceLastAccessed {
+		if err := enrichCredentialReportWithServiceLastAccessed(iamconn, report); err != nil {
+			return nil, time.Time{}, nil, err
 		}
+	}
+
+	generatedTime := time.Now()
+	if getReportOutput.GeneratedTime != nil {
+		generatedTime = *getReportOutput.GeneratedTime
+	}
+
+	return report, generatedTime, getReportOutput.Content, nil
+}
+
+// enrichCredentialReportWithVirtualMfa flags report rows for users that have
+// a virtual MFA device attached, since the credential report CSV itself only
+// distinguishes "mfa_active" (any MFA) and not the device type.
+func enrichCredentialReportWithVirtualMfa(iamconn credentialReportIAMAPI, report CredentialReport) error {
+	listMfaInput := &iam.ListVirtualMFADevicesInput{}
+	listMfaOutput, err := iamconn.ListVirtualMFADevices(listMfaInput)
+	if err != nil {
+		return err
+	}
 
-		// Extend the report with the virtual MFA info.
-		for _, row := range report {
-			if _, ok := accountsWithVirtualMfa[row.User]; ok {
-				row.MfaVirtual = true
+	// Run through the virtual MFA devices to create a set of users that
+	// have them enabled.  The user names are constructed to match those in
+	// the credential report.
+	accountsWithVirtualMfa := map[string]bool{}
+	serial, _ := regexp.Compile("^arn:aws:iam::[0-9]+:mfa/(.*)$")
+	for _, virtualMfa := range listMfaOutput.VirtualMFADevices {
+		match := serial.FindStringSubmatch(*virtualMfa.SerialNumber)
+		if match != nil && len(match) > 1 {
+			accountName := match[1]
+			if accountName == "root-account-mfa-device" {
+				accountName = "<root_account>"
 			}
+
+			accountsWithVirtualMfa[accountName] = true
 		}
+	}
 
-		// Store report in the resource state.
-		d.Set("report", flattenCredentialReport(report))
+	// Extend the report with the virtual MFA info.
+	for _, row := range report {
+		if _, ok := accountsWithVirtualMfa[row.User]; ok {
+			row.MfaVirtual = true
+		}
+	}
 
-		return nil
+	return nil
+}
+
+// credentialReportServiceLastAccessedPollTimeout bounds how long
+// enrichCredentialReportWithServiceLastAccessed will wait for a single
+// GenerateServiceLastAccessedDetails job to finish.
+const credentialReportServiceLastAccessedPollTimeout = 2 * time.Minute
+
+// enrichCredentialReportWithServiceLastAccessed attaches per-service last
+// accessed info to each report row by generating and polling an IAM service
+// last accessed details job for the row's user ARN. This is opt-in since it
+// costs one extra API round-trip (plus polling) per user.
+func enrichCredentialReportWithServiceLastAccessed(iamconn credentialReportIAMAPI, report CredentialReport) error {
+	for _, row := range report {
+		if row.Arn == "" {
+			continue
+		}
+
+		services, err := getServiceLastAccessedDetails(iamconn, row.Arn)
+		if err != nil {
+			return fmt.Errorf("getting service last accessed details for %s: %s", row.Arn, err)
+		}
+
+		row.Services = services
+	}
+
+	return nil
+}
+
+func getServiceLastAccessedDetails(iamconn credentialReportIAMAPI, arn string) ([]ServiceLastAccessed, error) {
+	generateOutput, err := iamconn.GenerateServiceLastAccessedDetails(&iam.GenerateServiceLastAccessedDetailsInput{
+		Arn: &arn,
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(credentialReportServiceLastAccessedPollTimeout)
+	backoff := 1 * time.Second
+
+	for {
+		getOutput, err := iamconn.GetServiceLastAccessedDetails(&iam.GetServiceLastAccessedDetailsInput{
+			JobId: generateOutput.JobId,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		switch *getOutput.JobStatus {
+		case iam.JobStatusTypeCompleted:
+			services := make([]ServiceLastAccessed, 0, len(getOutput.ServicesLastAccessed))
+			for _, svc := range getOutput.ServicesLastAccessed {
+				service := ServiceLastAccessed{
+					ServiceNamespace: *svc.ServiceNamespace,
+				}
+				if svc.LastAuthenticated != nil {
+					service.LastAuthenticated = svc.LastAuthenticated.Format(time.RFC3339)
+				}
+				if svc.LastAuthenticatedRegion != nil {
+					service.Region = *svc.LastAuthenticatedRegion
+				}
+				services = append(services, service)
+			}
+			return services, nil
+		case iam.JobStatusTypeFailed:
+			return nil, fmt.Errorf("service last accessed details job failed")
+		}
+
+		if time.Now().Add(backoff).After(deadline) {
+			return nil, fmt.Errorf("timed out after %s waiting for service last accessed details", credentialReportServiceLastAccessedPollTimeout)
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > credentialReportPollMaxBackoff {
+			backoff = credentialReportPollMaxBackoff
+		}
+	}
 }
 
 func resourceAwsIamCredentialReportDelete(d *schema.ResourceData, meta interface{}) error {
@@ -167,19 +658,109 @@ func resourceAwsIamCredentialReportDelete(d *schema.ResourceData, meta interface
 type CredentialReport = []*ReportRow
 
 type ReportRow struct {
-	User                string
-	PasswordEnabled     bool
-	PasswordLastUsed    string
-	PasswordLastChanged string
-	MfaActive           bool
-	MfaVirtual          bool
-	AccessKeys          []AccessKey
+	User                     string
+	Arn                      string
+	PasswordEnabled          bool
+	PasswordLastUsed         string
+	PasswordLastUsedKnown    bool
+	PasswordLastChanged      string
+	PasswordLastChangedKnown bool
+	MfaActive                bool
+	MfaVirtual               bool
+	AccessKeys               []AccessKey
+	Certs                    []Cert
+	Services                 []ServiceLastAccessed
 }
 
 type AccessKey struct {
-	Active       bool
-	LastUsedDate string
-	LastRotated  string
+	Active            bool
+	LastUsedDate      string
+	LastUsedDateKnown bool
+	LastRotated       string
+	LastRotatedKnown  bool
+	LastUsedRegion    string
+	LastUsedService   string
+}
+
+type Cert struct {
+	Active           bool
+	LastRotated      string
+	LastRotatedKnown bool
+}
+
+// ServiceLastAccessed is one entry of GetServiceLastAccessedDetails output
+// for a user, only populated when include_service_last_accessed is set.
+type ServiceLastAccessed struct {
+	ServiceNamespace  string
+	LastAuthenticated string
+	Region            string
+}
+
+// credentialReportExpectedColumns are the CSV columns this provider knows
+// how to interpret. parseCsvCredentialReport fails fast with a descriptive
+// error if AWS ever removes or renames one of these columns, rather than
+// silently reading empty strings or panicking on an out-of-range index.
+// Columns not in this list are logged and otherwise ignored.
+var credentialReportExpectedColumns = []string{
+	"user",
+	"arn",
+	"user_creation_time",
+	"password_enabled",
+	"password_last_used",
+	"password_last_changed",
+	"password_next_rotation",
+	"mfa_active",
+	"access_key_1_active",
+	"access_key_1_last_rotated",
+	"access_key_1_last_used_date",
+	"access_key_1_last_used_region",
+	"access_key_1_last_used_service",
+	"access_key_2_active",
+	"access_key_2_last_rotated",
+	"access_key_2_last_used_date",
+	"access_key_2_last_used_region",
+	"access_key_2_last_used_service",
+	"cert_1_active",
+	"cert_1_last_rotated",
+	"cert_2_active",
+	"cert_2_last_rotated",
+}
+
+// validateCredentialReportColumns checks the parsed CSV header against
+// credentialReportExpectedColumns. It errors if any of those columns is
+// absent, including ones parseCsvCredentialReport does not read; unrecognized
+// extra columns (e.g. AWS adding a new column before this provider knows
+// about it) are logged, not treated as fatal, since raw_csv exists precisely
+// so callers can fall back to their own parser in that case.
+func validateCredentialReportColumns(header map[string]int) error {
+	expected := map[string]bool{}
+	for _, column := range credentialReportExpectedColumns {
+		expected[column] = true
+	}
+
+	var missing, extra []string
+	for _, column := range credentialReportExpectedColumns {
+		if _, ok := header[column]; !ok {
+			missing = append(missing, column)
+		}
+	}
+	for column := range header {
+		if !expected[column] {
+			extra = append(extra, column)
+		}
+	}
+
+	if len(extra) > 0 {
+		sort.Strings(extra)
+		log.Printf("[WARN] IAM credential report CSV contains unrecognized columns %v; falling back to raw_csv for these", extra)
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+	return fmt.Errorf("IAM credential report CSV is missing expected columns %v", missing)
 }
 
 func parseCsvCredentialReport(content []byte) (CredentialReport, error) {
@@ -195,6 +776,10 @@ func parseCsvCredentialReport(content []byte) (CredentialReport, error) {
 		header[k] = i
 	}
 
+	if err := validateCredentialReportColumns(header); err != nil {
+		return nil, err
+	}
+
 	// Parse rows into CSV.
 	lines, err := reader.ReadAll()
 	if err != nil {
@@ -204,22 +789,56 @@ func parseCsvCredentialReport(content []byte) (CredentialReport, error) {
 	// Copy rows into the datatype.
 	rows := make([]*ReportRow, len(lines))
 	for i, line := range lines {
+		passwordLastUsed, passwordLastUsedKnown := parseCsvTimestamp(line[header["password_last_used"]])
+		passwordLastChanged, passwordLastChangedKnown := parseCsvTimestamp(line[header["password_last_changed"]])
+
+		accessKey1LastUsedDate, accessKey1LastUsedDateKnown := parseCsvTimestamp(line[header["access_key_1_last_used_date"]])
+		accessKey1LastRotated, accessKey1LastRotatedKnown := parseCsvTimestamp(line[header["access_key_1_last_rotated"]])
+		accessKey2LastUsedDate, accessKey2LastUsedDateKnown := parseCsvTimestamp(line[header["access_key_2_last_used_date"]])
+		accessKey2LastRotated, accessKey2LastRotatedKnown := parseCsvTimestamp(line[header["access_key_2_last_rotated"]])
+
+		cert1LastRotated, cert1LastRotatedKnown := parseCsvTimestamp(line[header["cert_1_last_rotated"]])
+		cert2LastRotated, cert2LastRotatedKnown := parseCsvTimestamp(line[header["cert_2_last_rotated"]])
+
 		rows[i] = &ReportRow{
-			User:                line[header["user"]],
-			PasswordEnabled:     parseCsvBool(line[header["password_enabled"]]),
-			PasswordLastUsed:    line[header["password_last_used"]],
-			PasswordLastChanged: line[header["password_last_changed"]],
-			MfaActive:           parseCsvBool(line[header["mfa_active"]]),
+			User:                     line[header["user"]],
+			Arn:                      line[header["arn"]],
+			PasswordEnabled:          parseCsvBool(line[header["password_enabled"]]),
+			PasswordLastUsed:         passwordLastUsed,
+			PasswordLastUsedKnown:    passwordLastUsedKnown,
+			PasswordLastChanged:      passwordLastChanged,
+			PasswordLastChangedKnown: passwordLastChangedKnown,
+			MfaActive:                parseCsvBool(line[header["mfa_active"]]),
 			AccessKeys: []AccessKey{
 				AccessKey{
-					Active:       parseCsvBool(line[header["access_key_1_active"]]),
-					LastUsedDate: line[header["access_key_1_last_used_date"]],
-					LastRotated:  line[header["access_key_1_last_rotated"]],
+					Active:            parseCsvBool(line[header["access_key_1_active"]]),
+					LastUsedDate:      accessKey1LastUsedDate,
+					LastUsedDateKnown: accessKey1LastUsedDateKnown,
+					LastRotated:       accessKey1LastRotated,
+					LastRotatedKnown:  accessKey1LastRotatedKnown,
+					LastUsedRegion:    line[header["access_key_1_last_used_region"]],
+					LastUsedService:   line[header["access_key_1_last_used_service"]],
 				},
 				AccessKey{
-					Active:       parseCsvBool(line[header["access_key_2_active"]]),
-					LastUsedDate: line[header["access_key_2_last_used_date"]],
-					LastRotated:  line[header["access_key_2_last_rotated"]],
+					Active:            parseCsvBool(line[header["access_key_2_active"]]),
+					LastUsedDate:      accessKey2LastUsedDate,
+					LastUsedDateKnown: accessKey2LastUsedDateKnown,
+					LastRotated:       accessKey2LastRotated,
+					LastRotatedKnown:  accessKey2LastRotatedKnown,
+					LastUsedRegion:    line[header["access_key_2_last_used_region"]],
+					LastUsedService:   line[header["access_key_2_last_used_service"]],
+				},
+			},
+			Certs: []Cert{
+				Cert{
+					Active:           parseCsvBool(line[header["cert_1_active"]]),
+					LastRotated:      cert1LastRotated,
+					LastRotatedKnown: cert1LastRotatedKnown,
+				},
+				Cert{
+					Active:           parseCsvBool(line[header["cert_2_active"]]),
+					LastRotated:      cert2LastRotated,
+					LastRotatedKnown: cert2LastRotatedKnown,
 				},
 			},
 		}
@@ -232,17 +851,46 @@ func parseCsvBool(csv string) bool {
 	return csv == "true"
 }
 
+// parseCsvTimestamp normalizes a credential report timestamp column to
+// RFC3339. AWS's sentinel values ("N/A" for fields that will never apply,
+// "no_information" for fields predating the feature) map to an empty string
+// with known == false; anything else that fails to parse is passed through
+// verbatim so a future AWS format change degrades gracefully instead of
+// losing data.
+func parseCsvTimestamp(raw string) (string, bool) {
+	switch raw {
+	case "N/A", "no_information":
+		return "", false
+	}
+
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return raw, true
+	}
+
+	return t.Format(time.RFC3339), true
+}
+
 func flattenCredentialReport(report CredentialReport) []map[string]interface{} {
 	out := make([]map[string]interface{}, 0)
 	for _, row := range report {
 		m := map[string]interface{}{
-			"user":                  row.User,
-			"password_enabled":      row.PasswordEnabled,
-			"password_last_used":    row.PasswordLastUsed,
-			"password_last_changed": row.PasswordLastChanged,
-			"mfa_active":            row.MfaActive,
-			"mfa_virtual":           row.MfaVirtual,
-			"access_keys":           flattenAccessKeys(row.AccessKeys),
+			"user":                        row.User,
+			"password_enabled":            row.PasswordEnabled,
+			"password_last_used":          row.PasswordLastUsed,
+			"password_last_used_known":    row.PasswordLastUsedKnown,
+			"password_last_changed":       row.PasswordLastChanged,
+			"password_last_changed_known": row.PasswordLastChangedKnown,
+			"mfa_active":                  row.MfaActive,
+			"mfa_virtual":                 row.MfaVirtual,
+			"access_keys":                 flattenAccessKeys(row.AccessKeys),
+			"services":                    flattenServicesLastAccessed(row.Services),
+		}
+		if len(row.Certs) > 0 {
+			m["cert_1"] = []map[string]interface{}{flattenCert(row.Certs[0])}
+		}
+		if len(row.Certs) > 1 {
+			m["cert_2"] = []map[string]interface{}{flattenCert(row.Certs[1])}
 		}
 		out = append(out, m)
 	}
@@ -253,11 +901,35 @@ func flattenAccessKeys(accessKeys []AccessKey) []map[string]interface{} {
 	out := make([]map[string]interface{}, 0)
 	for _, accessKey := range accessKeys {
 		m := map[string]interface{}{
-			"active":         accessKey.Active,
-			"last_used_date": accessKey.LastUsedDate,
-			"last_rotated":   accessKey.LastRotated,
+			"active":               accessKey.Active,
+			"last_used_date":       accessKey.LastUsedDate,
+			"last_used_date_known": accessKey.LastUsedDateKnown,
+			"last_rotated":         accessKey.LastRotated,
+			"last_rotated_known":   accessKey.LastRotatedKnown,
+			"last_used_region":     accessKey.LastUsedRegion,
+			"last_used_service":    accessKey.LastUsedService,
 		}
 		out = append(out, m)
 	}
 	return out
 }
+
+func flattenCert(cert Cert) map[string]interface{} {
+	return map[string]interface{}{
+		"active":             cert.Active,
+		"last_rotated":       cert.LastRotated,
+		"last_rotated_known": cert.LastRotatedKnown,
+	}
+}
+
+func flattenServicesLastAccessed(services []ServiceLastAccessed) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(services))
+	for _, service := range services {
+		out = append(out, map[string]interface{}{
+			"service_namespace":  service.ServiceNamespace,
+			"last_authenticated": service.LastAuthenticated,
+			"region":             service.Region,
+		})
+	}
+	return out
+}
@@ -0,0 +1,344 @@
+// This is a Fugue-specific read-only resource type that fans the IAM
+// Credential Report out across a set of assumed roles (one per AWS
+// account) and merges the results into a single report, so a nightly scan
+// of dozens of accounts doesn't require a separate resource per account.
+
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// credentialReportAggregatedDefaultMaxAge is the max_report_age used for
+// each per-account fetch. It is not exposed as an argument since the
+// aggregated resource is meant for unattended, scheduled scans rather than
+// interactive tuning.
+const credentialReportAggregatedDefaultMaxAge = 4 * time.Hour
+
+func resourceAwsIamCredentialReportAggregated() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceAwsIamCredentialReportAggregatedUpdate,
+		Read:   resourceAwsIamCredentialReportAggregatedRead,
+		Update: resourceAwsIamCredentialReportAggregatedUpdate,
+		Delete: resourceAwsIamCredentialReportAggregatedDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"assume_role": {
+				Type:     schema.TypeList,
+				Required: true,
+				MinItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"role_arn": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"external_id": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"session_name": {
+							Type:     schema.TypeString,
+							Optional: true,
+							Default:  "terraform-iam-credential-report",
+						},
+					},
+				},
+			},
+			"max_concurrency": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     5,
+				Description: "Maximum number of accounts to scan concurrently.",
+			},
+			"report": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: credentialReportAggregatedRowSchema(),
+				},
+			},
+			"failed_accounts": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"role_arn": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"account_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"error": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// credentialReportAggregatedRowSchema is the per-account report schema: the
+// same shape as the single-account report, plus account_id.
+func credentialReportAggregatedRowSchema() map[string]*schema.Schema {
+	s := map[string]*schema.Schema{
+		"account_id": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"user": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"password_enabled": {
+			Type:     schema.TypeBool,
+			Computed: true,
+		},
+		"password_last_used": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"password_last_used_known": {
+			Type:     schema.TypeBool,
+			Computed: true,
+		},
+		"password_last_changed": {
+			Type:     schema.TypeString,
+			Computed: true,
+		},
+		"password_last_changed_known": {
+			Type:     schema.TypeBool,
+			Computed: true,
+		},
+		"mfa_active": {
+			Type:     schema.TypeBool,
+			Computed: true,
+		},
+		"mfa_virtual": {
+			Type:     schema.TypeBool,
+			Computed: true,
+		},
+		"access_keys": {
+			Type:     schema.TypeList,
+			Computed: true,
+			Elem: &schema.Resource{
+				Schema: credentialReportAccessKeySchema(),
+			},
+		},
+		"cert_1": {
+			Type:     schema.TypeList,
+			Computed: true,
+			MaxItems: 1,
+			Elem: &schema.Resource{
+				Schema: credentialReportCertSchema(),
+			},
+		},
+		"cert_2": {
+			Type:     schema.TypeList,
+			Computed: true,
+			MaxItems: 1,
+			Elem: &schema.Resource{
+				Schema: credentialReportCertSchema(),
+			},
+		},
+		"services": {
+			Type:     schema.TypeList,
+			Computed: true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"service_namespace": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"last_authenticated": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"region": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+				},
+			},
+		},
+	}
+	return s
+}
+
+func resourceAwsIamCredentialReportAggregatedUpdate(d *schema.ResourceData, meta interface{}) error {
+	d.SetId("iam-credential-report-aggregated")
+	return resourceAwsIamCredentialReportAggregatedRead(d, meta)
+}
+
+func resourceAwsIamCredentialReportAggregatedRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*AWSClient)
+
+	assumeRoles := d.Get("assume_role").([]interface{})
+	maxConcurrency := d.Get("max_concurrency").(int)
+
+	results := fetchCredentialReportsForAccounts(client, assumeRoles, maxConcurrency)
+
+	report := make([]map[string]interface{}, 0)
+	failedAccounts := make([]map[string]interface{}, 0)
+	for _, res := range results {
+		if res.err != nil {
+			failedAccounts = append(failedAccounts, map[string]interface{}{
+				"role_arn":   res.roleArn,
+				"account_id": res.accountID,
+				"error":      res.err.Error(),
+			})
+			continue
+		}
+
+		for _, row := range flattenCredentialReport(res.report) {
+			row["account_id"] = res.accountID
+			report = append(report, row)
+		}
+	}
+
+	d.Set("report", report)
+	d.Set("failed_accounts", failedAccounts)
+
+	return nil
+}
+
+func resourceAwsIamCredentialReportAggregatedDelete(d *schema.ResourceData, meta interface{}) error {
+	return nil
+}
+
+// accountCredentialReportResult is the outcome of fetching the credential
+// report for a single assumed-role account.
+type accountCredentialReportResult struct {
+	roleArn   string
+	accountID string
+	report    CredentialReport
+	err       error
+}
+
+// indexedAssumeRoleJob pairs an assume_role block with its position in the
+// configured list, so results can be restored to submission order after
+// being fetched out of order by the worker pool.
+type indexedAssumeRoleJob struct {
+	index int
+	role  map[string]interface{}
+}
+
+// fetchCredentialReportsForAccounts assumes each role in parallel (bounded
+// by maxConcurrency) and fetches that account's credential report. Errors
+// for individual accounts are captured in the result rather than aborting
+// the whole run. Results are returned in the same order as assumeRoles,
+// regardless of which account's fetch finishes first, so report and
+// failed_accounts don't reorder themselves on every refresh.
+func fetchCredentialReportsForAccounts(client *AWSClient, assumeRoles []interface{}, maxConcurrency int) []accountCredentialReportResult {
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+
+	jobs := make(chan indexedAssumeRoleJob, len(assumeRoles))
+	out := make([]accountCredentialReportResult, len(assumeRoles))
+
+	var wg sync.WaitGroup
+	for w := 0; w < maxConcurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				out[job.index] = fetchCredentialReportForAccount(client, job.role)
+			}
+		}()
+	}
+
+	for i, ar := range assumeRoles {
+		jobs <- indexedAssumeRoleJob{index: i, role: ar.(map[string]interface{})}
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	return out
+}
+
+func fetchCredentialReportForAccount(client *AWSClient, assumeRole map[string]interface{}) accountCredentialReportResult {
+	roleArn := assumeRole["role_arn"].(string)
+	externalID := assumeRole["external_id"].(string)
+	sessionName := assumeRole["session_name"].(string)
+
+	iamconn, accountID, err := assumedRoleIamClient(client, roleArn, externalID, sessionName)
+	if err != nil {
+		return accountCredentialReportResult{roleArn: roleArn, err: fmt.Errorf("assuming role: %s", err)}
+	}
+
+	report, _, _, err := getCredentialReport(iamconn, credentialReportAggregatedDefaultMaxAge, false, false)
+	if err != nil {
+		return accountCredentialReportResult{roleArn: roleArn, accountID: accountID, err: err}
+	}
+
+	return accountCredentialReportResult{roleArn: roleArn, accountID: accountID, report: report}
+}
+
+// assumedRoleIamClient assumes roleArn using the provider's own session and
+// returns an IAM client scoped to the resulting temporary credentials, along
+// with the target account ID parsed out of the assumed role's ARN.
+func assumedRoleIamClient(client *AWSClient, roleArn, externalID, sessionName string) (*iam.IAM, string, error) {
+	assumeRoleInput := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(roleArn),
+		RoleSessionName: aws.String(sessionName),
+	}
+	if externalID != "" {
+		assumeRoleInput.ExternalId = aws.String(externalID)
+	}
+
+	assumeRoleOutput, err := client.stsconn.AssumeRole(assumeRoleInput)
+	if err != nil {
+		return nil, "", err
+	}
+
+	creds := credentials.NewStaticCredentials(
+		*assumeRoleOutput.Credentials.AccessKeyId,
+		*assumeRoleOutput.Credentials.SecretAccessKey,
+		*assumeRoleOutput.Credentials.SessionToken,
+	)
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:      client.session.Config.Region,
+		Credentials: creds,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	accountID := ""
+	if assumeRoleOutput.AssumedRoleUser != nil && assumeRoleOutput.AssumedRoleUser.Arn != nil {
+		accountID = accountIDFromArn(*assumeRoleOutput.AssumedRoleUser.Arn)
+	}
+
+	return iam.New(sess), accountID, nil
+}
+
+// accountIDFromArn extracts the account ID component of an ARN
+// (arn:partition:service:region:account-id:resource).
+func accountIDFromArn(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 5 {
+		return ""
+	}
+	return parts[4]
+}
@@ -0,0 +1,367 @@
+package aws
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+func TestParseCsvTimestamp(t *testing.T) {
+	cases := []struct {
+		name      string
+		raw       string
+		wantKnown bool
+		want      string
+	}{
+		{name: "N/A sentinel is unknown", raw: "N/A", wantKnown: false, want: ""},
+		{name: "no_information sentinel is unknown", raw: "no_information", wantKnown: false, want: ""},
+		{name: "valid RFC3339 timestamp is known", raw: "2020-01-02T03:04:05Z", wantKnown: true, want: "2020-01-02T03:04:05Z"},
+		{name: "unparseable non-sentinel value passes through", raw: "garbage", wantKnown: true, want: "garbage"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, known := parseCsvTimestamp(tc.raw)
+			if known != tc.wantKnown || got != tc.want {
+				t.Errorf("parseCsvTimestamp(%q) = (%q, %v), want (%q, %v)", tc.raw, got, known, tc.want, tc.wantKnown)
+			}
+		})
+	}
+}
+
+func TestValidateCredentialReportColumns(t *testing.T) {
+	fullHeader := map[string]int{}
+	for i, c := range credentialReportExpectedColumns {
+		fullHeader[c] = i
+	}
+
+	t.Run("all expected columns present", func(t *testing.T) {
+		if err := validateCredentialReportColumns(fullHeader); err != nil {
+			t.Errorf("expected no error, got %s", err)
+		}
+	})
+
+	t.Run("unrecognized extra column is tolerated", func(t *testing.T) {
+		header := map[string]int{}
+		for k, v := range fullHeader {
+			header[k] = v
+		}
+		header["some_new_aws_column"] = len(header)
+
+		if err := validateCredentialReportColumns(header); err != nil {
+			t.Errorf("expected extra columns to be tolerated, got error: %s", err)
+		}
+	})
+
+	t.Run("missing column is an error", func(t *testing.T) {
+		header := map[string]int{}
+		for k, v := range fullHeader {
+			header[k] = v
+		}
+		delete(header, "password_last_used")
+
+		err := validateCredentialReportColumns(header)
+		if err == nil {
+			t.Fatal("expected an error for a missing column, got nil")
+		}
+		if !strings.Contains(err.Error(), "password_last_used") {
+			t.Errorf("expected error to mention the missing column, got: %s", err)
+		}
+	})
+}
+
+// sampleCredentialReportCSV returns a single-row credential report CSV
+// covering every expected column, for tests that need parseable content
+// rather than specific column values.
+func sampleCredentialReportCSV() []byte {
+	header := strings.Join(credentialReportExpectedColumns, ",")
+	row := make([]string, len(credentialReportExpectedColumns))
+	values := map[string]string{
+		"user":                           "alice",
+		"arn":                            "arn:aws:iam::123456789012:user/alice",
+		"user_creation_time":             "2020-01-01T00:00:00Z",
+		"password_enabled":               "true",
+		"password_last_used":             "N/A",
+		"password_last_changed":          "2020-01-01T00:00:00Z",
+		"password_next_rotation":         "N/A",
+		"mfa_active":                     "false",
+		"access_key_1_active":            "true",
+		"access_key_1_last_rotated":      "2020-01-01T00:00:00Z",
+		"access_key_1_last_used_date":    "no_information",
+		"access_key_1_last_used_region":  "us-east-1",
+		"access_key_1_last_used_service": "iam",
+		"access_key_2_active":            "false",
+		"access_key_2_last_rotated":      "N/A",
+		"access_key_2_last_used_date":    "N/A",
+		"access_key_2_last_used_region":  "N/A",
+		"access_key_2_last_used_service": "N/A",
+		"cert_1_active":                  "false",
+		"cert_1_last_rotated":            "N/A",
+		"cert_2_active":                  "false",
+		"cert_2_last_rotated":            "N/A",
+	}
+	for i, column := range credentialReportExpectedColumns {
+		row[i] = values[column]
+	}
+	return []byte(header + "\n" + strings.Join(row, ",") + "\n")
+}
+
+func TestParseCsvCredentialReport(t *testing.T) {
+	report, err := parseCsvCredentialReport(sampleCredentialReportCSV())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(report) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(report))
+	}
+
+	got := report[0]
+	if got.User != "alice" {
+		t.Errorf("User = %q, want alice", got.User)
+	}
+	if got.PasswordLastUsedKnown {
+		t.Errorf("PasswordLastUsedKnown = true, want false for N/A sentinel")
+	}
+	if !got.PasswordLastChangedKnown || got.PasswordLastChanged != "2020-01-01T00:00:00Z" {
+		t.Errorf("PasswordLastChanged = (%q, known=%v), want (2020-01-01T00:00:00Z, true)", got.PasswordLastChanged, got.PasswordLastChangedKnown)
+	}
+	if got.AccessKeys[0].LastUsedDateKnown {
+		t.Errorf("AccessKeys[0].LastUsedDateKnown = true, want false for no_information sentinel")
+	}
+	if got.AccessKeys[0].LastUsedRegion != "us-east-1" {
+		t.Errorf("AccessKeys[0].LastUsedRegion = %q, want us-east-1", got.AccessKeys[0].LastUsedRegion)
+	}
+}
+
+func TestParseCsvCredentialReportMissingColumn(t *testing.T) {
+	var columns []string
+	for _, c := range credentialReportExpectedColumns {
+		if c == "mfa_active" {
+			continue
+		}
+		columns = append(columns, c)
+	}
+	content := strings.Join(columns, ",") + "\n"
+
+	if _, err := parseCsvCredentialReport([]byte(content)); err == nil {
+		t.Fatal("expected an error when a required column is missing, got nil")
+	}
+}
+
+func TestAccountIDFromArn(t *testing.T) {
+	cases := []struct {
+		name string
+		arn  string
+		want string
+	}{
+		{name: "assumed role arn", arn: "arn:aws:sts::123456789012:assumed-role/example-role/session", want: "123456789012"},
+		{name: "malformed arn", arn: "not-an-arn", want: ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := accountIDFromArn(tc.arn); got != tc.want {
+				t.Errorf("accountIDFromArn(%q) = %q, want %q", tc.arn, got, tc.want)
+			}
+		})
+	}
+}
+
+// fakeCredentialReportIAMAPI is a credentialReportIAMAPI implementation that
+// lets tests script GetCredentialReport's responses without standing up a
+// real IAM client.
+type fakeCredentialReportIAMAPI struct {
+	getCredentialReportFn func() (*iam.GetCredentialReportOutput, error)
+
+	generateCalls int
+	getCalls      int
+}
+
+func (f *fakeCredentialReportIAMAPI) GetCredentialReport(*iam.GetCredentialReportInput) (*iam.GetCredentialReportOutput, error) {
+	f.getCalls++
+	return f.getCredentialReportFn()
+}
+
+func (f *fakeCredentialReportIAMAPI) GenerateCredentialReport(*iam.GenerateCredentialReportInput) (*iam.GenerateCredentialReportOutput, error) {
+	f.generateCalls++
+	return &iam.GenerateCredentialReportOutput{}, nil
+}
+
+func (f *fakeCredentialReportIAMAPI) ListVirtualMFADevices(*iam.ListVirtualMFADevicesInput) (*iam.ListVirtualMFADevicesOutput, error) {
+	return &iam.ListVirtualMFADevicesOutput{}, nil
+}
+
+func (f *fakeCredentialReportIAMAPI) GenerateServiceLastAccessedDetails(*iam.GenerateServiceLastAccessedDetailsInput) (*iam.GenerateServiceLastAccessedDetailsOutput, error) {
+	return &iam.GenerateServiceLastAccessedDetailsOutput{}, nil
+}
+
+func (f *fakeCredentialReportIAMAPI) GetServiceLastAccessedDetails(*iam.GetServiceLastAccessedDetailsInput) (*iam.GetServiceLastAccessedDetailsOutput, error) {
+	return &iam.GetServiceLastAccessedDetailsOutput{}, nil
+}
+
+// reportInProgressErr mimics the awserr.Error AWS returns while a credential
+// report is still being generated.
+func reportInProgressErr() error {
+	return awserr.New("ReportInProgress", "credential report is being created", nil)
+}
+
+func reportNotPresentErr() error {
+	return awserr.New("ReportNotPresent", "credential report does not exist", nil)
+}
+
+func TestGetCredentialReportReusesFreshReport(t *testing.T) {
+	content := sampleCredentialReportCSV()
+	fake := &fakeCredentialReportIAMAPI{
+		getCredentialReportFn: func() (*iam.GetCredentialReportOutput, error) {
+			return &iam.GetCredentialReportOutput{
+				GeneratedTime: aws.Time(time.Now()),
+				Content:       content,
+			}, nil
+		},
+	}
+
+	_, _, _, err := getCredentialReport(fake, time.Hour, false, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if fake.generateCalls != 0 {
+		t.Errorf("generateCalls = %d, want 0 for a fresh cached report", fake.generateCalls)
+	}
+	if fake.getCalls != 1 {
+		t.Errorf("getCalls = %d, want 1", fake.getCalls)
+	}
+}
+
+func TestGetCredentialReportRegeneratesStaleReport(t *testing.T) {
+	content := sampleCredentialReportCSV()
+	first := true
+	fake := &fakeCredentialReportIAMAPI{
+		getCredentialReportFn: func() (*iam.GetCredentialReportOutput, error) {
+			if first {
+				first = false
+				return &iam.GetCredentialReportOutput{
+					GeneratedTime: aws.Time(time.Now().Add(-2 * time.Hour)),
+					Content:       content,
+				}, nil
+			}
+			return &iam.GetCredentialReportOutput{
+				GeneratedTime: aws.Time(time.Now()),
+				Content:       content,
+			}, nil
+		},
+	}
+
+	_, _, _, err := getCredentialReport(fake, time.Hour, false, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if fake.generateCalls != 1 {
+		t.Errorf("generateCalls = %d, want 1 for a stale report", fake.generateCalls)
+	}
+}
+
+func TestGetCredentialReportGeneratesWhenNoneExists(t *testing.T) {
+	content := sampleCredentialReportCSV()
+	first := true
+	fake := &fakeCredentialReportIAMAPI{
+		getCredentialReportFn: func() (*iam.GetCredentialReportOutput, error) {
+			if first {
+				first = false
+				return nil, reportNotPresentErr()
+			}
+			return &iam.GetCredentialReportOutput{
+				GeneratedTime: aws.Time(time.Now()),
+				Content:       content,
+			}, nil
+		},
+	}
+
+	_, _, _, err := getCredentialReport(fake, time.Hour, false, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if fake.generateCalls != 1 {
+		t.Errorf("generateCalls = %d, want 1 when ReportNotPresent", fake.generateCalls)
+	}
+}
+
+// TestGetCredentialReportSkipsGenerateWhenAlreadyInProgress covers the
+// regression where the initial freshness check treated ReportInProgress as a
+// fatal error instead of waiting for the in-flight generation: a second
+// Read racing a report generation kicked off elsewhere must poll for the
+// existing job rather than calling GenerateCredentialReport again.
+func TestGetCredentialReportSkipsGenerateWhenAlreadyInProgress(t *testing.T) {
+	content := sampleCredentialReportCSV()
+	first := true
+	fake := &fakeCredentialReportIAMAPI{
+		getCredentialReportFn: func() (*iam.GetCredentialReportOutput, error) {
+			if first {
+				first = false
+				return nil, reportInProgressErr()
+			}
+			return &iam.GetCredentialReportOutput{
+				GeneratedTime: aws.Time(time.Now()),
+				Content:       content,
+			}, nil
+		},
+	}
+
+	_, _, _, err := getCredentialReport(fake, time.Hour, false, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if fake.generateCalls != 0 {
+		t.Errorf("generateCalls = %d, want 0 when a report is already in progress", fake.generateCalls)
+	}
+	if fake.getCalls != 2 {
+		t.Errorf("getCalls = %d, want 2 (initial check + one poll)", fake.getCalls)
+	}
+}
+
+func TestPollCredentialReportSucceedsAfterTransientInProgress(t *testing.T) {
+	content := sampleCredentialReportCSV()
+	calls := 0
+	fake := &fakeCredentialReportIAMAPI{
+		getCredentialReportFn: func() (*iam.GetCredentialReportOutput, error) {
+			calls++
+			if calls < 3 {
+				return nil, reportInProgressErr()
+			}
+			return &iam.GetCredentialReportOutput{
+				GeneratedTime: aws.Time(time.Now()),
+				Content:       content,
+			}, nil
+		},
+	}
+
+	out, err := pollCredentialReport(fake)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if calls != 3 {
+		t.Errorf("GetCredentialReport called %d times, want 3", calls)
+	}
+	if string(out.Content) != string(content) {
+		t.Errorf("unexpected report content returned")
+	}
+}
+
+func TestPollCredentialReportReturnsNonRetryableErrorImmediately(t *testing.T) {
+	fake := &fakeCredentialReportIAMAPI{
+		getCredentialReportFn: func() (*iam.GetCredentialReportOutput, error) {
+			return nil, awserr.New("AccessDenied", "not authorized", nil)
+		},
+	}
+
+	_, err := pollCredentialReport(fake)
+	if err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+	if fake.getCalls != 1 {
+		t.Errorf("getCalls = %d, want 1 for a non-retryable error", fake.getCalls)
+	}
+}